@@ -0,0 +1,227 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+)
+
+const (
+	// BlockSize is the target uncompressed size of a data block, in bytes.
+	// The record that pushes a block past this target is still included in
+	// full; blocks are never split mid-record.
+	BlockSize int = 4 * 1024
+
+	// RestartInterval is the number of records between full-key "restart
+	// points" inside a block. Between restarts, records store only the
+	// suffix of their key that differs from the previous one, so Get binary
+	// searches the restarts and then linearly scans at most RestartInterval
+	// records instead of the whole block.
+	RestartInterval int = 16
+
+	// compressionNone marks a block as stored uncompressed on disk.
+	// Reserved for future codecs (snappy, zstd, ...).
+	compressionNone byte = 0
+
+	// blockTrailerSize is the CRC32C checksum + compression flag appended
+	// after every block's payload on disk.
+	blockTrailerSize int = 5
+)
+
+var castagnoliTable = crc32.MakeTable(crc32.Castagnoli)
+
+// blockBuilder accumulates encoded records for a single data block in
+// sorted-key order. Every RestartInterval-th record is a restart point
+// that stores its key in full; records in between store sharedPrefixLen +
+// unsharedLen + valueLen + the unshared key suffix + the value.
+type blockBuilder struct {
+	buf        bytes.Buffer
+	restarts   []uint32
+	lastKey    string
+	numRecords int
+}
+
+func newBlockBuilder() *blockBuilder {
+	return &blockBuilder{}
+}
+
+// add appends a record to the block being built.
+func (b *blockBuilder) add(key, value string, seqNum uint64, tombstone bool) {
+	shared := 0
+	if b.numRecords%RestartInterval == 0 {
+		b.restarts = append(b.restarts, uint32(b.buf.Len()))
+	} else {
+		shared = sharedPrefixLen(b.lastKey, key)
+	}
+	unshared := key[shared:]
+
+	var scratch [binary.MaxVarintLen64]byte
+	writeUvarint(&b.buf, scratch[:], uint64(shared))
+	writeUvarint(&b.buf, scratch[:], uint64(len(unshared)))
+	writeUvarint(&b.buf, scratch[:], uint64(len(value)))
+	writeUvarint(&b.buf, scratch[:], seqNum)
+	if tombstone {
+		b.buf.WriteByte(1)
+	} else {
+		b.buf.WriteByte(0)
+	}
+	b.buf.WriteString(unshared)
+	b.buf.WriteString(value)
+
+	b.lastKey = key
+	b.numRecords++
+}
+
+// estimatedSize returns the size the block would have if finished right
+// now, including the restart-point trailer but excluding the on-disk
+// checksum + compression suffix.
+func (b *blockBuilder) estimatedSize() int {
+	return b.buf.Len() + len(b.restarts)*4 + 4
+}
+
+func (b *blockBuilder) empty() bool {
+	return b.numRecords == 0
+}
+
+// finish serializes the block: payload, restart offsets, restart count,
+// then a CRC32C checksum over all of the above and a compression flag.
+func (b *blockBuilder) finish() []byte {
+	for _, r := range b.restarts {
+		var tmp [4]byte
+		binary.LittleEndian.PutUint32(tmp[:], r)
+		b.buf.Write(tmp[:])
+	}
+	var countBuf [4]byte
+	binary.LittleEndian.PutUint32(countBuf[:], uint32(len(b.restarts)))
+	b.buf.Write(countBuf[:])
+
+	payload := b.buf.Bytes()
+	checksum := crc32.Checksum(payload, castagnoliTable)
+
+	out := make([]byte, len(payload)+blockTrailerSize)
+	copy(out, payload)
+	binary.LittleEndian.PutUint32(out[len(payload):], checksum)
+	out[len(out)-1] = compressionNone
+	return out
+}
+
+func (b *blockBuilder) reset() {
+	b.buf.Reset()
+	b.restarts = b.restarts[:0]
+	b.lastKey = ""
+	b.numRecords = 0
+}
+
+// block is a decoded, checksum-verified data block ready for lookups.
+type block struct {
+	payload  []byte   // shared/unshared/value records, with the restart trailer stripped off
+	restarts []uint32 // byte offsets into payload where each restart point begins
+}
+
+// readBlock reads a block's payload + trailer at offset from f and
+// verifies its CRC32C checksum before returning the decoded block.
+func readBlock(f *os.File, offset, length uint32) (*block, error) {
+	raw := make([]byte, int(length)+blockTrailerSize)
+	if _, err := f.ReadAt(raw, int64(offset)); err != nil {
+		return nil, fmt.Errorf("read block at offset %d: %w", offset, err)
+	}
+
+	payload := raw[:length]
+	wantChecksum := binary.LittleEndian.Uint32(raw[length:])
+	if gotChecksum := crc32.Checksum(payload, castagnoliTable); gotChecksum != wantChecksum {
+		return nil, fmt.Errorf("block at offset %d: checksum mismatch, data file is corrupt", offset)
+	}
+
+	numRestarts := binary.LittleEndian.Uint32(payload[len(payload)-4:])
+	restartsStart := len(payload) - 4 - int(numRestarts)*4
+	restarts := make([]uint32, numRestarts)
+	for i := range restarts {
+		restarts[i] = binary.LittleEndian.Uint32(payload[restartsStart+i*4:])
+	}
+
+	return &block{payload: payload[:restartsStart], restarts: restarts}, nil
+}
+
+// decodeRecordAt decodes the record stored at byte offset pos, given the
+// full key of the previous record in the block (needed to expand the
+// shared-prefix encoding), and returns it along with the offset of the
+// next record.
+func (blk *block) decodeRecordAt(pos int, prevKey string) (key, value string, seqNum uint64, tombstone bool, nextPos int) {
+	entry, consumed := decodeEntryHeader(blk.payload[pos:])
+	pos += consumed
+	unsharedKey := string(blk.payload[pos : pos+entry.unsharedLen])
+	pos += entry.unsharedLen
+	value = string(blk.payload[pos : pos+entry.valueLen])
+	pos += entry.valueLen
+	key = prevKey[:entry.sharedLen] + unsharedKey
+	return key, value, entry.seqNum, entry.tombstone, pos
+}
+
+// seekRestart binary-searches the block's restart points for the last one
+// whose key is <= key.
+func (blk *block) seekRestart(key string) int {
+	low, high := 0, len(blk.restarts)-1
+	for low < high {
+		mid := (low + high + 1) / 2
+		if blk.keyAtRestart(mid) <= key {
+			low = mid
+		} else {
+			high = mid - 1
+		}
+	}
+	return low
+}
+
+// keyAtRestart decodes the full key stored at a restart point; restart
+// points always have shared == 0.
+func (blk *block) keyAtRestart(i int) string {
+	pos := int(blk.restarts[i])
+	entry, consumed := decodeEntryHeader(blk.payload[pos:])
+	pos += consumed
+	return string(blk.payload[pos : pos+entry.unsharedLen])
+}
+
+// entryHeader is the fixed set of fields preceding the unshared key bytes
+// and value bytes of every record in a block.
+type entryHeader struct {
+	sharedLen   int
+	unsharedLen int
+	valueLen    int
+	seqNum      uint64
+	tombstone   bool
+}
+
+func decodeEntryHeader(b []byte) (entryHeader, int) {
+	shared, n1 := binary.Uvarint(b)
+	unshared, n2 := binary.Uvarint(b[n1:])
+	valueLen, n3 := binary.Uvarint(b[n1+n2:])
+	seqNum, n4 := binary.Uvarint(b[n1+n2+n3:])
+	tombstone := b[n1+n2+n3+n4] == 1
+	consumed := n1 + n2 + n3 + n4 + 1
+	return entryHeader{
+		sharedLen:   int(shared),
+		unsharedLen: int(unshared),
+		valueLen:    int(valueLen),
+		seqNum:      seqNum,
+		tombstone:   tombstone,
+	}, consumed
+}
+
+func writeUvarint(buf *bytes.Buffer, scratch []byte, v uint64) {
+	n := binary.PutUvarint(scratch, v)
+	buf.Write(scratch[:n])
+}
+
+func sharedPrefixLen(a, b string) int {
+	n := len(a)
+	if len(b) < n {
+		n = len(b)
+	}
+	i := 0
+	for i < n && a[i] == b[i] {
+		i++
+	}
+	return i
+}
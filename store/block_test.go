@@ -0,0 +1,117 @@
+package store
+
+import (
+	"os"
+	"testing"
+)
+
+// TestBlockBuilderRoundTrip writes records spanning more than one restart
+// interval through a blockBuilder, then reads them back via readBlock +
+// decodeRecordAt to confirm the shared-prefix encoding and restart points
+// decode back to the original keys/values in order.
+func TestBlockBuilderRoundTrip(t *testing.T) {
+	keys := []string{"app", "apple", "application", "banana", "band", "bandana"}
+
+	b := newBlockBuilder()
+	for i, k := range keys {
+		b.add(k, k+"-value", uint64(i), false)
+	}
+	encoded := b.finish()
+
+	f, err := os.CreateTemp("", "block_test_")
+	if err != nil {
+		t.Fatalf("create temp file: %v", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if _, err := f.Write(encoded); err != nil {
+		t.Fatalf("write encoded block: %v", err)
+	}
+
+	blk, err := readBlock(f, 0, uint32(len(encoded)-blockTrailerSize))
+	if err != nil {
+		t.Fatalf("readBlock: %v", err)
+	}
+
+	pos := 0
+	prevKey := ""
+	for i, wantKey := range keys {
+		key, value, seqNum, tombstone, newPos := blk.decodeRecordAt(pos, prevKey)
+		if key != wantKey {
+			t.Fatalf("record %d: key = %q, want %q", i, key, wantKey)
+		}
+		if value != wantKey+"-value" {
+			t.Fatalf("record %d: value = %q, want %q", i, value, wantKey+"-value")
+		}
+		if seqNum != uint64(i) {
+			t.Fatalf("record %d: seqNum = %d, want %d", i, seqNum, i)
+		}
+		if tombstone {
+			t.Fatalf("record %d: tombstone = true, want false", i)
+		}
+		pos, prevKey = newPos, key
+	}
+}
+
+// TestReadBlockDetectsCorruption flips a byte in an encoded block's
+// payload and verifies readBlock rejects it via the CRC32C checksum
+// instead of silently returning corrupt data.
+func TestReadBlockDetectsCorruption(t *testing.T) {
+	b := newBlockBuilder()
+	b.add("key", "value", 0, false)
+	encoded := b.finish()
+	encoded[0] ^= 0xFF // corrupt the first payload byte
+
+	f, err := os.CreateTemp("", "block_test_")
+	if err != nil {
+		t.Fatalf("create temp file: %v", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if _, err := f.Write(encoded); err != nil {
+		t.Fatalf("write encoded block: %v", err)
+	}
+
+	if _, err := readBlock(f, 0, uint32(len(encoded)-blockTrailerSize)); err == nil {
+		t.Fatal("readBlock with corrupted payload returned no error, want checksum mismatch")
+	}
+}
+
+// TestSeekRestartFindsLastRestartAtOrBeforeKey covers the binary search
+// restart-point lookup chunk0-1 introduced to avoid scanning a whole
+// block from its start.
+func TestSeekRestartFindsLastRestartAtOrBeforeKey(t *testing.T) {
+	b := newBlockBuilder()
+	keys := make([]string, 0, 40)
+	for i := 0; i < 40; i++ {
+		keys = append(keys, string(rune('a'+i/26))+string(rune('a'+i%26)))
+	}
+	for i, k := range keys {
+		b.add(k, k, uint64(i), false)
+	}
+	encoded := b.finish()
+
+	f, err := os.CreateTemp("", "block_test_")
+	if err != nil {
+		t.Fatalf("create temp file: %v", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if _, err := f.Write(encoded); err != nil {
+		t.Fatalf("write encoded block: %v", err)
+	}
+
+	blk, err := readBlock(f, 0, uint32(len(encoded)-blockTrailerSize))
+	if err != nil {
+		t.Fatalf("readBlock: %v", err)
+	}
+
+	if len(blk.restarts) < 2 {
+		t.Fatalf("got %d restart points, want at least 2 to exercise the binary search", len(blk.restarts))
+	}
+
+	target := keys[20]
+	if got := blk.keyAtRestart(blk.seekRestart(target)); got > target {
+		t.Fatalf("seekRestart(%q) landed on restart key %q, which is past it", target, got)
+	}
+}
@@ -0,0 +1,103 @@
+package store
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/tferdous17/genesis/utils"
+)
+
+// blockCacheKey identifies a cached block by the SSTable it came from and
+// its byte offset within that table's data file.
+type blockCacheKey struct {
+	sstCounter  uint32
+	blockOffset uint32
+}
+
+type cacheEntry struct {
+	key  blockCacheKey
+	blk  *block
+	size int
+}
+
+// BlockCache is a concurrent, byte-budgeted LRU cache of decoded data
+// blocks shared across every SSTable in a store. SSTable.Get consults it
+// before issuing a Seek+ReadFull against the data file, so repeated
+// lookups into a hot block avoid the syscall entirely.
+type BlockCache struct {
+	mu       sync.Mutex
+	capacity int
+	used     int
+	entries  map[blockCacheKey]*list.Element
+	lru      *list.List // front = most recently used
+
+	hits   uint64
+	misses uint64
+}
+
+// NewBlockCache creates a cache with a byte-size budget of capacityBytes,
+// not an entry-count limit, since blocks can vary in size.
+func NewBlockCache(capacityBytes int) *BlockCache {
+	return &BlockCache{
+		capacity: capacityBytes,
+		entries:  make(map[blockCacheKey]*list.Element),
+		lru:      list.New(),
+	}
+}
+
+func (c *BlockCache) get(key blockCacheKey) (*block, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		c.misses++
+		utils.Logf("BLOCK CACHE MISS: sst_%d offset %d", key.sstCounter, key.blockOffset)
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	c.hits++
+	utils.Logf("BLOCK CACHE HIT: sst_%d offset %d", key.sstCounter, key.blockOffset)
+	return elem.Value.(*cacheEntry).blk, true
+}
+
+// put inserts blk under key, evicting least-recently-used entries until it
+// fits within capacity. A block bigger than the whole cache bypasses
+// caching rather than evicting every other entry for a one-shot read.
+func (c *BlockCache) put(key blockCacheKey, blk *block, size int) {
+	if size > c.capacity {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*cacheEntry)
+		c.used += size - entry.size
+		entry.blk, entry.size = blk, size
+		c.lru.MoveToFront(elem)
+	} else {
+		elem := c.lru.PushFront(&cacheEntry{key: key, blk: blk, size: size})
+		c.entries[key] = elem
+		c.used += size
+	}
+
+	for c.used > c.capacity {
+		back := c.lru.Back()
+		if back == nil {
+			break
+		}
+		entry := back.Value.(*cacheEntry)
+		c.lru.Remove(back)
+		delete(c.entries, entry.key)
+		c.used -= entry.size
+	}
+}
+
+// Stats reports cumulative hit/miss counts for instrumentation.
+func (c *BlockCache) Stats() (hits, misses uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits, c.misses
+}
@@ -0,0 +1,67 @@
+package store
+
+import "testing"
+
+func TestBlockCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewBlockCache(30)
+
+	k1 := blockCacheKey{sstCounter: 1, blockOffset: 0}
+	k2 := blockCacheKey{sstCounter: 1, blockOffset: 10}
+	k3 := blockCacheKey{sstCounter: 1, blockOffset: 20}
+
+	c.put(k1, &block{}, 10)
+	c.put(k2, &block{}, 10)
+	c.put(k3, &block{}, 10) // cache now full at 30/30
+
+	// Touch k1 so it's most-recently-used; k2 becomes the next eviction
+	// candidate ahead of it.
+	if _, ok := c.get(k1); !ok {
+		t.Fatal("get(k1) miss, want hit")
+	}
+
+	// Inserting a fourth entry must evict k2 (least recently used), not k1.
+	k4 := blockCacheKey{sstCounter: 1, blockOffset: 30}
+	c.put(k4, &block{}, 10)
+
+	if _, ok := c.get(k1); !ok {
+		t.Fatal("get(k1) miss after eviction, want hit (was recently used)")
+	}
+	if _, ok := c.get(k2); ok {
+		t.Fatal("get(k2) hit after eviction, want miss (was least recently used)")
+	}
+	if _, ok := c.get(k3); !ok {
+		t.Fatal("get(k3) miss after eviction, want hit")
+	}
+}
+
+func TestBlockCacheSkipsOversizedBlockWithoutEvictingOthers(t *testing.T) {
+	c := NewBlockCache(20)
+
+	k1 := blockCacheKey{sstCounter: 1, blockOffset: 0}
+	c.put(k1, &block{}, 10)
+
+	oversized := blockCacheKey{sstCounter: 1, blockOffset: 10}
+	c.put(oversized, &block{}, 50) // bigger than the whole cache
+
+	if _, ok := c.get(oversized); ok {
+		t.Fatal("get(oversized) hit, want miss (should have bypassed the cache)")
+	}
+	if _, ok := c.get(k1); !ok {
+		t.Fatal("get(k1) miss, want hit (oversized put must not evict existing entries)")
+	}
+}
+
+func TestBlockCacheStatsCountHitsAndMisses(t *testing.T) {
+	c := NewBlockCache(100)
+	k := blockCacheKey{sstCounter: 1, blockOffset: 0}
+
+	c.get(k) // miss
+	c.put(k, &block{}, 10)
+	c.get(k) // hit
+	c.get(k) // hit
+
+	hits, misses := c.Stats()
+	if hits != 2 || misses != 1 {
+		t.Fatalf("Stats() = (hits=%d, misses=%d), want (2, 1)", hits, misses)
+	}
+}
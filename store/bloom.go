@@ -0,0 +1,168 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"hash/fnv"
+	"io"
+	"math"
+	"os"
+
+	"github.com/tferdous17/genesis/utils"
+)
+
+// bloomFormatVersion is bumped whenever the on-disk .bloom layout changes
+// in a way older readers can't parse.
+const bloomFormatVersion byte = 1
+
+// BloomFilter is a probabilistic membership structure backing SSTable.Get:
+// a negative answer means a key is definitely absent, letting Get skip the
+// data file entirely; a positive answer only ever means "maybe present".
+type BloomFilter struct {
+	file         *os.File
+	bitSetSize   uint32
+	numHashFuncs uint32
+	bitSet       []bool
+}
+
+// NewBloomFilter wraps the .bloom file backing this SSTable's filter.
+// InitBloomFilterAttrs must be called once the number of entries is known
+// before Add/MightContain are usable.
+func NewBloomFilter(file *os.File) *BloomFilter {
+	return &BloomFilter{file: file}
+}
+
+// InitBloomFilterAttrs sizes the filter for numItems entries, targeting a
+// ~1% false positive rate.
+func (bf *BloomFilter) InitBloomFilterAttrs(numItems uint32) {
+	bf.bitSetSize = optimalBitSetSize(numItems)
+	bf.numHashFuncs = optimalNumHashFuncs(bf.bitSetSize, numItems)
+	bf.bitSet = make([]bool, bf.bitSetSize)
+}
+
+func optimalBitSetSize(numItems uint32) uint32 {
+	if numItems == 0 {
+		numItems = 1
+	}
+	return uint32(math.Ceil(-1 * float64(numItems) * math.Log(0.01) / math.Pow(math.Log(2), 2)))
+}
+
+func optimalNumHashFuncs(bitSetSize, numItems uint32) uint32 {
+	if numItems == 0 {
+		numItems = 1
+	}
+	n := uint32(math.Round(float64(bitSetSize) / float64(numItems) * math.Log(2)))
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// Add marks key as present in the filter.
+func (bf *BloomFilter) Add(key string) {
+	for i := uint32(0); i < bf.numHashFuncs; i++ {
+		bf.bitSet[bf.hash(key, i)] = true
+	}
+}
+
+// MightContain reports whether key could be present. False positives are
+// possible; false negatives are not.
+func (bf *BloomFilter) MightContain(key string) bool {
+	for i := uint32(0); i < bf.numHashFuncs; i++ {
+		if !bf.bitSet[bf.hash(key, i)] {
+			return false
+		}
+	}
+	return true
+}
+
+// Save bit-packs the filter's bitset (1 bit per entry instead of 1 byte)
+// and writes it to the backing .bloom file behind a small header of
+// bitSetSize, numHashFuncs and numItems (uvarints) plus a format-version
+// byte, trailed by a CRC32C so a reload can detect truncation or
+// corruption. numItems is informational only; it isn't needed to
+// reconstruct the filter but lets tooling inspect how full it is.
+func (bf *BloomFilter) Save(numItems uint32) error {
+	var scratch [binary.MaxVarintLen64]byte
+	buf := new(bytes.Buffer)
+	buf.WriteByte(bloomFormatVersion)
+	writeUvarint(buf, scratch[:], uint64(bf.bitSetSize))
+	writeUvarint(buf, scratch[:], uint64(bf.numHashFuncs))
+	writeUvarint(buf, scratch[:], uint64(numItems))
+
+	packed := make([]byte, (bf.bitSetSize+7)/8)
+	for i, set := range bf.bitSet {
+		if set {
+			packed[i/8] |= 1 << uint(i%8)
+		}
+	}
+	buf.Write(packed)
+
+	checksum := crc32.Checksum(buf.Bytes(), castagnoliTable)
+	var crcBytes [4]byte
+	binary.LittleEndian.PutUint32(crcBytes[:], checksum)
+	buf.Write(crcBytes[:])
+
+	return utils.WriteToFile(buf.Bytes(), bf.file)
+}
+
+// LoadBloomFilter reconstructs a usable BloomFilter from an existing
+// .bloom file, recovering bitSetSize and numHashFuncs from its header
+// instead of recomputing them, so a reopened SSTable doesn't have to
+// rebuild its filter from every key on restart.
+func LoadBloomFilter(file *os.File) (*BloomFilter, error) {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("seek bloom file: %w", err)
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("read bloom file: %w", err)
+	}
+	if len(data) < 5 {
+		return nil, fmt.Errorf("bloom file: too short to contain a header and checksum")
+	}
+
+	body := data[:len(data)-4]
+	wantChecksum := binary.LittleEndian.Uint32(data[len(data)-4:])
+	if gotChecksum := crc32.Checksum(body, castagnoliTable); gotChecksum != wantChecksum {
+		return nil, fmt.Errorf("bloom file: checksum mismatch, filter is corrupt")
+	}
+
+	version := body[0]
+	if version != bloomFormatVersion {
+		return nil, fmt.Errorf("bloom file: unsupported format version %d", version)
+	}
+
+	pos := 1
+	bitSetSize, n := binary.Uvarint(body[pos:])
+	pos += n
+	numHashFuncs, n := binary.Uvarint(body[pos:])
+	pos += n
+	_, n = binary.Uvarint(body[pos:]) // numItems; not needed to rebuild the filter
+	pos += n
+
+	packed := body[pos:]
+	bitSet := make([]bool, bitSetSize)
+	for i := range bitSet {
+		bitSet[i] = packed[i/8]&(1<<uint(i%8)) != 0
+	}
+
+	return &BloomFilter{
+		file:         file,
+		bitSetSize:   uint32(bitSetSize),
+		numHashFuncs: uint32(numHashFuncs),
+		bitSet:       bitSet,
+	}, nil
+}
+
+// hash derives the i-th hash of key via double hashing (Kirsch-Mitzenmacher),
+// avoiding numHashFuncs independent hash computations per operation.
+func (bf *BloomFilter) hash(key string, i uint32) uint32 {
+	h1 := fnv.New32a()
+	h1.Write([]byte(key))
+	h2 := fnv.New32()
+	h2.Write([]byte(key))
+	return (h1.Sum32() + i*h2.Sum32()) % bf.bitSetSize
+}
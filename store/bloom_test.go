@@ -0,0 +1,94 @@
+package store
+
+import (
+	"os"
+	"testing"
+)
+
+func TestBloomFilterSaveLoadRoundTrip(t *testing.T) {
+	f, err := os.CreateTemp("", "bloom_test_")
+	if err != nil {
+		t.Fatalf("create temp file: %v", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	keys := []string{"alpha", "bravo", "charlie", "delta"}
+	bf := NewBloomFilter(f)
+	bf.InitBloomFilterAttrs(uint32(len(keys)))
+	for _, k := range keys {
+		bf.Add(k)
+	}
+	if err := bf.Save(uint32(len(keys))); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	loaded, err := LoadBloomFilter(f)
+	if err != nil {
+		t.Fatalf("LoadBloomFilter: %v", err)
+	}
+	if loaded.bitSetSize != bf.bitSetSize || loaded.numHashFuncs != bf.numHashFuncs {
+		t.Fatalf("loaded params = (%d, %d), want (%d, %d)",
+			loaded.bitSetSize, loaded.numHashFuncs, bf.bitSetSize, bf.numHashFuncs)
+	}
+	for _, k := range keys {
+		if !loaded.MightContain(k) {
+			t.Fatalf("loaded filter reports %q absent, want present (no false negatives)", k)
+		}
+	}
+}
+
+// TestBloomFilterSaveIsBitPacked pins the on-disk size to ceil(bitSetSize/8)
+// bytes of bitset plus the small header/checksum, guarding against a
+// regression back to one byte per bit.
+func TestBloomFilterSaveIsBitPacked(t *testing.T) {
+	f, err := os.CreateTemp("", "bloom_test_")
+	if err != nil {
+		t.Fatalf("create temp file: %v", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	bf := NewBloomFilter(f)
+	bf.InitBloomFilterAttrs(1000)
+	if err := bf.Save(1000); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+
+	packedBitsetBytes := int64((bf.bitSetSize + 7) / 8)
+	// Saved file must be close to the packed bitset size, not the
+	// unpacked one-byte-per-bit size it would be pre-chunk0-6.
+	if info.Size() > packedBitsetBytes+32 {
+		t.Fatalf("saved bloom file is %d bytes for a %d-bit set, want close to the %d-byte packed size",
+			info.Size(), bf.bitSetSize, packedBitsetBytes)
+	}
+}
+
+func TestLoadBloomFilterRejectsChecksumMismatch(t *testing.T) {
+	f, err := os.CreateTemp("", "bloom_test_")
+	if err != nil {
+		t.Fatalf("create temp file: %v", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	bf := NewBloomFilter(f)
+	bf.InitBloomFilterAttrs(10)
+	bf.Add("key")
+	if err := bf.Save(10); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	if _, err := f.WriteAt([]byte{0xFF}, 1); err != nil {
+		t.Fatalf("corrupt bloom file: %v", err)
+	}
+
+	if _, err := LoadBloomFilter(f); err == nil {
+		t.Fatal("LoadBloomFilter with corrupted header returned no error, want checksum mismatch")
+	}
+}
@@ -0,0 +1,367 @@
+package store
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tferdous17/genesis/utils"
+)
+
+const (
+	// NumLevels is the number of LSM levels a Compactor manages, L0..L(NumLevels-1).
+	NumLevels = 7
+
+	// L0CompactionTrigger is how many flushed tables L0 tolerates (with
+	// overlapping key ranges) before they're merged down into L1.
+	L0CompactionTrigger = 4
+
+	// L1BytesThreshold is the size at which L1 triggers compaction into
+	// L2; LevelSizeMultiplier grows the threshold 10x per level below
+	// that, matching LevelDB/Pebble's level sizing.
+	L1BytesThreshold    = 10 * 1024 * 1024
+	LevelSizeMultiplier = 10
+
+	// MaxCompactionOutputFileBytes caps how large a single SST produced
+	// by a compaction can grow before a new output file is started.
+	MaxCompactionOutputFileBytes = 2 * 1024 * 1024
+
+	compactionPollInterval = 500 * time.Millisecond
+)
+
+// level holds the SSTables belonging to one level of the LSM tree. L0's
+// tables may have overlapping key ranges; L1 and below are kept disjoint.
+type level struct {
+	mu     sync.RWMutex
+	tables []*SSTable
+}
+
+func (l *level) snapshot() []*SSTable {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	out := make([]*SSTable, len(l.tables))
+	copy(out, l.tables)
+	return out
+}
+
+func (l *level) totalBytes() uint32 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	var total uint32
+	for _, t := range l.tables {
+		total += t.sizeInBytes
+	}
+	return total
+}
+
+// Compactor owns the leveled layout of SSTables for one store directory:
+// L0 accepts flushed tables as-is, and a background goroutine merges
+// overflowing levels downward, collapsing superseded key versions and
+// dropping tombstones as it goes.
+type Compactor struct {
+	directory  string
+	manifest   *manifest
+	levels     [NumLevels]*level
+	blockCache *BlockCache // shared with every table this Compactor tracks; nil means "no cache"
+
+	wakeCh chan struct{}
+	stopCh chan struct{}
+}
+
+// NewCompactor opens (or creates) the manifest for directory and replays
+// it so the in-memory level layout matches what was on disk before
+// restart.
+func NewCompactor(directory string) (*Compactor, error) {
+	m, err := openManifest(directory)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Compactor{
+		directory: directory,
+		manifest:  m,
+		wakeCh:    make(chan struct{}, 1),
+		stopCh:    make(chan struct{}),
+	}
+	for i := range c.levels {
+		c.levels[i] = &level{}
+	}
+
+	liveFiles, err := replayManifest(directory)
+	if err != nil {
+		return nil, err
+	}
+	var maxFileNum uint32
+	for levelNum, fileNums := range liveFiles {
+		for _, fileNum := range fileNums {
+			sst, err := OpenSSTable(directory, fileNum)
+			if err != nil {
+				return nil, err
+			}
+			c.levels[levelNum].tables = append(c.levels[levelNum].tables, sst)
+			if fileNum > maxFileNum {
+				maxFileNum = fileNum
+			}
+		}
+	}
+	// Restarting must never hand out a file number the manifest still
+	// references, or the next flush/compaction silently overwrites a live
+	// SSTable (see seedSstTableCounter).
+	seedSstTableCounter(maxFileNum)
+
+	return c, nil
+}
+
+// Start launches the background compaction loop. Call Stop to end it.
+func (c *Compactor) Start() {
+	go c.loop()
+}
+
+func (c *Compactor) Stop() {
+	close(c.stopCh)
+}
+
+func (c *Compactor) loop() {
+	ticker := time.NewTicker(compactionPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stopCh:
+			return
+		case <-c.wakeCh:
+			c.maybeCompact()
+		case <-ticker.C:
+			c.maybeCompact()
+		}
+	}
+}
+
+// AddL0Table registers a freshly flushed SSTable in L0 and wakes the
+// compaction loop; it's the leveled counterpart to a bare
+// InitSSTableOnDisk call.
+func (c *Compactor) AddL0Table(sst *SSTable) {
+	sst.blockCache = c.blockCache
+
+	c.levels[0].mu.Lock()
+	c.levels[0].tables = append(c.levels[0].tables, sst)
+	c.levels[0].mu.Unlock()
+
+	edit := manifestEdit{add: true, level: 0, fileNum: sst.sstCounter, minKey: sst.minKey, maxKey: sst.maxKey}
+	if err := c.manifest.append([]manifestEdit{edit}); err != nil {
+		utils.LogRED("manifest append failed: %v", err)
+	}
+
+	select {
+	case c.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+func levelThreshold(levelNum int) uint32 {
+	threshold := uint32(L1BytesThreshold)
+	for i := 1; i < levelNum; i++ {
+		threshold *= LevelSizeMultiplier
+	}
+	return threshold
+}
+
+func (c *Compactor) maybeCompact() {
+	if len(c.levels[0].snapshot()) >= L0CompactionTrigger {
+		c.compactLevel(0)
+		return
+	}
+	for levelNum := 1; levelNum < NumLevels-1; levelNum++ {
+		if c.levels[levelNum].totalBytes() >= levelThreshold(levelNum) {
+			c.compactLevel(levelNum)
+			return
+		}
+	}
+}
+
+// compactLevel merges levelNum's tables (all of L0, or the oldest table
+// below L0) with every overlapping table in levelNum+1, writes the
+// merged, tombstone-free result into one or more new SSTables in
+// levelNum+1, and atomically swaps the manifest and in-memory level state.
+func (c *Compactor) compactLevel(levelNum int) {
+	target := levelNum + 1
+
+	var inputs []*SSTable
+	if levelNum == 0 {
+		inputs = c.levels[0].snapshot()
+	} else if oldest := c.oldestTable(levelNum); oldest != nil {
+		inputs = []*SSTable{oldest}
+	}
+	if len(inputs) == 0 {
+		return
+	}
+
+	minKey, maxKey := inputs[0].minKey, inputs[0].maxKey
+	for _, t := range inputs[1:] {
+		if t.minKey < minKey {
+			minKey = t.minKey
+		}
+		if t.maxKey > maxKey {
+			maxKey = t.maxKey
+		}
+	}
+	overlapping := c.overlapping(target, minKey, maxKey)
+	inputs = append(inputs, overlapping...)
+
+	// Only the bottom-most level can prove no older copy of a key survives
+	// beneath it; everywhere else a tombstone must be carried forward or a
+	// deeper, untouched copy of the key would resurface once this
+	// compaction lands.
+	merged := mergeAndCollapse(inputs, target == NumLevels-1)
+	outputs := writeCompactionOutputs(c.directory, merged)
+	for _, o := range outputs {
+		o.blockCache = c.blockCache
+	}
+
+	edits := make([]manifestEdit, 0, len(inputs)+len(outputs))
+	for _, t := range inputs {
+		edits = append(edits, manifestEdit{add: false, level: c.levelOf(t), fileNum: t.sstCounter})
+	}
+	for _, t := range outputs {
+		edits = append(edits, manifestEdit{add: true, level: target, fileNum: t.sstCounter, minKey: t.minKey, maxKey: t.maxKey})
+	}
+	if err := c.manifest.append(edits); err != nil {
+		utils.LogRED("compaction manifest append failed: %v", err)
+		return
+	}
+
+	c.removeTables(levelNum, inputs)
+	c.removeTables(target, overlapping)
+
+	// The manifest edit above is durable and inputs (which already
+	// includes overlapping, folded in above) is out of its levels now, so
+	// nothing will look these tables up again; reclaim the fds and disk
+	// space they're holding. A Get that grabbed one of these tables from
+	// level.snapshot() just before removeTables ran may still be mid-read
+	// against it; closeAndRemove waits for it via sst.closeMu before
+	// touching the files, so that read completes normally instead of
+	// failing against an already-closed/removed file.
+	for _, t := range inputs {
+		t.closeAndRemove()
+	}
+
+	c.levels[target].mu.Lock()
+	c.levels[target].tables = append(c.levels[target].tables, outputs...)
+	sort.Slice(c.levels[target].tables, func(i, j int) bool {
+		return c.levels[target].tables[i].minKey < c.levels[target].tables[j].minKey
+	})
+	c.levels[target].mu.Unlock()
+}
+
+// writeCompactionOutputs splits merged records into as many SSTables as
+// needed to keep each one under MaxCompactionOutputFileBytes.
+func writeCompactionOutputs(directory string, merged []Record) []*SSTable {
+	var outputs []*SSTable
+	for start := 0; start < len(merged); {
+		end := start + 1
+		size := recordApproxSize(merged[start])
+		for end < len(merged) && size < MaxCompactionOutputFileBytes {
+			size += recordApproxSize(merged[end])
+			end++
+		}
+		batch := merged[start:end]
+		out := InitSSTableOnDisk(directory, &batch)
+		outputs = append(outputs, out)
+		start = end
+	}
+	return outputs
+}
+
+func recordApproxSize(r Record) int {
+	return len(r.Key) + len(r.Value) + 16 // entry header + restart/trailer amortized overhead
+}
+
+func (c *Compactor) oldestTable(levelNum int) *SSTable {
+	tables := c.levels[levelNum].snapshot()
+	if len(tables) == 0 {
+		return nil
+	}
+	oldest := tables[0]
+	for _, t := range tables[1:] {
+		if t.sstCounter < oldest.sstCounter {
+			oldest = t
+		}
+	}
+	return oldest
+}
+
+// overlapping returns every table in levelNum whose key range intersects [minKey, maxKey].
+func (c *Compactor) overlapping(levelNum int, minKey, maxKey string) []*SSTable {
+	var out []*SSTable
+	for _, t := range c.levels[levelNum].snapshot() {
+		if t.maxKey < minKey || t.minKey > maxKey {
+			continue
+		}
+		out = append(out, t)
+	}
+	return out
+}
+
+func (c *Compactor) removeTables(levelNum int, remove []*SSTable) {
+	if len(remove) == 0 {
+		return
+	}
+	removed := make(map[uint32]bool, len(remove))
+	for _, t := range remove {
+		removed[t.sstCounter] = true
+	}
+
+	c.levels[levelNum].mu.Lock()
+	kept := c.levels[levelNum].tables[:0]
+	for _, t := range c.levels[levelNum].tables {
+		if !removed[t.sstCounter] {
+			kept = append(kept, t)
+		}
+	}
+	c.levels[levelNum].tables = kept
+	c.levels[levelNum].mu.Unlock()
+}
+
+func (c *Compactor) levelOf(target *SSTable) int {
+	for levelNum := range c.levels {
+		for _, t := range c.levels[levelNum].snapshot() {
+			if t.sstCounter == target.sstCounter {
+				return levelNum
+			}
+		}
+	}
+	return -1
+}
+
+// mergeAndCollapse merges the full contents of inputs into one
+// ascending-key stream via a MergingIterator, keeping only the winning
+// version of each key (see MergingIterator's sequence-number tie-break).
+// Tombstones are dropped only when dropTombstones is set; otherwise they
+// are carried into the output so a key that's merely shadowed by this
+// compaction doesn't resurrect an older, untouched copy sitting deeper.
+func mergeAndCollapse(inputs []*SSTable, dropTombstones bool) []Record {
+	sorted := make([]*SSTable, len(inputs))
+	copy(sorted, inputs)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].sstCounter < sorted[j].sstCounter })
+
+	iters := make([]Iterator, len(sorted))
+	for i, t := range sorted {
+		iters[i] = t.NewIterator()
+	}
+	merged := NewMergingIterator(iters...)
+	defer merged.Close()
+
+	var out []Record
+	for ; merged.Valid(); merged.Next() {
+		if merged.tombstone() && dropTombstones {
+			continue
+		}
+		out = append(out, Record{
+			Key:            merged.Key(),
+			Value:          merged.Value(),
+			SequenceNumber: merged.sequenceNumber(),
+			Tombstone:      merged.tombstone(),
+		})
+	}
+	return out
+}
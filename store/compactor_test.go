@@ -0,0 +1,166 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+// TestNewCompactorSeedsCounterPastManifest reproduces a restart against an
+// existing store directory: process 1 flushes key "a" into sst_N, then
+// (simulating process 2 starting fresh) sstTableCounter resets to 0.
+// NewCompactor must reseed it from the manifest before any new table is
+// created, or the next flush overwrites sst_N and silently loses "a".
+func TestNewCompactorSeedsCounterPastManifest(t *testing.T) {
+	abs, err := os.MkdirTemp("..", "compactor_test_")
+	if err != nil {
+		t.Fatalf("mkdir temp: %v", err)
+	}
+	dir := filepath.Base(abs)
+	defer os.RemoveAll(abs)
+
+	s1, err := NewStore(dir, 0)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	a := []Record{{Key: "a", Value: "1"}}
+	s1.Flush(&a)
+	s1.Close()
+
+	// Simulate process 2 starting with no in-memory history of process 1.
+	atomic.StoreUint32(&sstTableCounter, 0)
+
+	s2, err := NewStore(dir, 0)
+	if err != nil {
+		t.Fatalf("reopen store: %v", err)
+	}
+	defer s2.Close()
+
+	b := []Record{{Key: "b", Value: "2"}}
+	s2.Flush(&b)
+
+	if _, err := s2.Get("a"); err != nil {
+		t.Fatalf("Get(a) after restart = %v, want nil (file must not have been overwritten)", err)
+	}
+	if v, err := s2.Get("b"); err != nil || v != "2" {
+		t.Fatalf("Get(b) = %q, %v, want \"2\", nil", v, err)
+	}
+}
+
+// TestMergeAndCollapseCarriesTombstoneExceptAtBottomLevel exercises
+// mergeAndCollapse directly: a tombstone must survive a non-bottom-level
+// merge (so a still-live older copy further down stays shadowed) but be
+// dropped once the merge targets the bottom-most level.
+func TestMergeAndCollapseCarriesTombstoneExceptAtBottomLevel(t *testing.T) {
+	abs, err := os.MkdirTemp("..", "merge_test_")
+	if err != nil {
+		t.Fatalf("mkdir temp: %v", err)
+	}
+	dir := filepath.Base(abs)
+	defer os.RemoveAll(abs)
+
+	tombstoned := InitSSTableOnDisk(dir, &[]Record{{Key: "x", Tombstone: true}})
+
+	carried := mergeAndCollapse([]*SSTable{tombstoned}, false)
+	if len(carried) != 1 || !carried[0].Tombstone {
+		t.Fatalf("mergeAndCollapse(dropTombstones=false) = %+v, want tombstone carried forward", carried)
+	}
+
+	dropped := mergeAndCollapse([]*SSTable{tombstoned}, true)
+	if len(dropped) != 0 {
+		t.Fatalf("mergeAndCollapse(dropTombstones=true) = %+v, want tombstone dropped", dropped)
+	}
+}
+
+// TestCompactLevelRemovesInputFilesFromDisk confirms an L0->L1 compaction
+// deletes the input SSTables' .data/.bloom files instead of leaving them
+// as orphans once they're no longer referenced by any level or the
+// manifest. Drives the Compactor directly (no Store, background loop not
+// started) so the single compactLevel call below is the only one that
+// ever runs.
+func TestCompactLevelRemovesInputFilesFromDisk(t *testing.T) {
+	abs, err := os.MkdirTemp("..", "compact_cleanup_test_")
+	if err != nil {
+		t.Fatalf("mkdir temp: %v", err)
+	}
+	dir := filepath.Base(abs)
+	defer os.RemoveAll(abs)
+
+	c, err := NewCompactor(dir)
+	if err != nil {
+		t.Fatalf("new compactor: %v", err)
+	}
+
+	var inputs []*SSTable
+	for i := 0; i < L0CompactionTrigger; i++ {
+		key := string(rune('a' + i))
+		sst := InitSSTableOnDisk(dir, &[]Record{{Key: key, Value: key}})
+		c.AddL0Table(sst)
+		inputs = append(inputs, sst)
+	}
+
+	c.compactLevel(0)
+
+	for _, in := range inputs {
+		base := getNextSstFilename(dir, in.sstCounter)
+		if _, err := os.Stat(base + DataFileExtension); !os.IsNotExist(err) {
+			t.Fatalf("sst_%d%s still exists after compaction, want removed", in.sstCounter, DataFileExtension)
+		}
+		if _, err := os.Stat(base + BloomFileExtension); !os.IsNotExist(err) {
+			t.Fatalf("sst_%d%s still exists after compaction, want removed", in.sstCounter, BloomFileExtension)
+		}
+	}
+
+	for _, lvl1 := range c.levels[1].snapshot() {
+		for i := 0; i < L0CompactionTrigger; i++ {
+			key := string(rune('a' + i))
+			if v, err := lvl1.Get(key); err == nil && v != key {
+				t.Fatalf("Get(%q) in compacted output = %q, want %q", key, v, key)
+			}
+		}
+	}
+}
+
+// TestCompactLevelWithOverlapRemovesEachFileOnce drives a second L0->L1
+// compaction whose key range overlaps the first compaction's L1 output.
+// inputs folds overlapping into itself before cleanup runs, so this
+// exercises the path where a naive "close inputs, then close overlapping
+// again" would double-close/double-remove the same files.
+func TestCompactLevelWithOverlapRemovesEachFileOnce(t *testing.T) {
+	abs, err := os.MkdirTemp("..", "compact_overlap_test_")
+	if err != nil {
+		t.Fatalf("mkdir temp: %v", err)
+	}
+	dir := filepath.Base(abs)
+	defer os.RemoveAll(abs)
+
+	c, err := NewCompactor(dir)
+	if err != nil {
+		t.Fatalf("new compactor: %v", err)
+	}
+
+	for i := 0; i < L0CompactionTrigger; i++ {
+		key := string(rune('a' + i))
+		c.AddL0Table(InitSSTableOnDisk(dir, &[]Record{{Key: key, Value: "old-" + key}}))
+	}
+	c.compactLevel(0) // seeds L1 with a table covering keys a..d
+
+	for i := 0; i < L0CompactionTrigger; i++ {
+		key := string(rune('a' + i))
+		c.AddL0Table(InitSSTableOnDisk(dir, &[]Record{{Key: key, Value: "new-" + key}}))
+	}
+	c.compactLevel(0) // overlaps and must replace the first L1 table
+
+	lvl1 := c.levels[1].snapshot()
+	if len(lvl1) != 1 {
+		t.Fatalf("got %d L1 tables after overlapping compaction, want 1", len(lvl1))
+	}
+	for i := 0; i < L0CompactionTrigger; i++ {
+		key := string(rune('a' + i))
+		v, err := lvl1[0].Get(key)
+		if err != nil || v != "new-"+key {
+			t.Fatalf("Get(%q) = %q, %v, want %q, nil", key, v, err, "new-"+key)
+		}
+	}
+}
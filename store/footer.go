@@ -0,0 +1,113 @@
+package store
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+)
+
+// sstMagic identifies a valid SSTable .data file; Open rejects anything
+// else outright rather than trying to make sense of it.
+var sstMagic = [4]byte{'S', 'S', 'T', 'B'}
+
+// sstFormatVersion is bumped whenever the on-disk layout changes in a way
+// older readers can't parse. OpenSSTable rejects files whose footer
+// reports a version newer than this build understands, instead of
+// silently misreading them.
+const sstFormatVersion byte = 1
+
+// footerSize is the fixed size, in bytes, of the trailer appended to
+// every .data file: magic + version + 4 (offset, length) handles +
+// entry count + CRC32C. The handles are what let the footer stay a
+// constant size even though the data it points to (keys, the block
+// index) is variable-length and lives earlier in the file.
+const footerSize = len(sstMagic) + 1 + 4*2*4 + 4 + 4
+
+// footer is the self-describing trailer written at the end of every
+// .data file. Reopening a table starts here: validate magic + CRC, check
+// the format version, then use the handles to locate the block index and
+// the min/max key bytes without re-scanning the file.
+type footer struct {
+	version byte
+
+	blockIndexOffset uint32
+	blockIndexLen    uint32
+
+	bloomOffset uint32 // 0 until the bloom filter is folded into .data; see BloomFileExtension
+	bloomLen    uint32
+
+	minKeyOffset uint32
+	minKeyLen    uint32
+	maxKeyOffset uint32
+	maxKeyLen    uint32
+
+	entryCount uint32
+}
+
+func (f footer) encode() []byte {
+	buf := make([]byte, 0, footerSize)
+	buf = append(buf, sstMagic[:]...)
+	buf = append(buf, f.version)
+
+	var tmp [4]byte
+	putU32 := func(v uint32) {
+		binary.LittleEndian.PutUint32(tmp[:], v)
+		buf = append(buf, tmp[:]...)
+	}
+	putU32(f.blockIndexOffset)
+	putU32(f.blockIndexLen)
+	putU32(f.bloomOffset)
+	putU32(f.bloomLen)
+	putU32(f.minKeyOffset)
+	putU32(f.minKeyLen)
+	putU32(f.maxKeyOffset)
+	putU32(f.maxKeyLen)
+	putU32(f.entryCount)
+
+	checksum := crc32.Checksum(buf, castagnoliTable)
+	putU32(checksum)
+	return buf
+}
+
+func decodeFooter(raw []byte) (footer, error) {
+	if len(raw) != footerSize {
+		return footer{}, fmt.Errorf("footer: expected %d bytes, got %d", footerSize, len(raw))
+	}
+	if string(raw[:len(sstMagic)]) != string(sstMagic[:]) {
+		return footer{}, fmt.Errorf("footer: bad magic, not a genesis SSTable data file")
+	}
+
+	body := raw[:len(raw)-4]
+	wantChecksum := binary.LittleEndian.Uint32(raw[len(raw)-4:])
+	if gotChecksum := crc32.Checksum(body, castagnoliTable); gotChecksum != wantChecksum {
+		return footer{}, fmt.Errorf("footer: checksum mismatch, data file is corrupt")
+	}
+
+	version := raw[len(sstMagic)]
+	if version > sstFormatVersion {
+		return footer{}, fmt.Errorf("footer: format version %d is newer than this build supports (%d)", version, sstFormatVersion)
+	}
+	if version == 0 {
+		return footer{}, fmt.Errorf("footer: format version 0 is invalid")
+	}
+
+	pos := len(sstMagic) + 1
+	readU32 := func() uint32 {
+		v := binary.LittleEndian.Uint32(raw[pos:])
+		pos += 4
+		return v
+	}
+
+	f := footer{version: version}
+	f.blockIndexOffset = readU32()
+	f.blockIndexLen = readU32()
+	f.bloomOffset = readU32()
+	f.bloomLen = readU32()
+	f.minKeyOffset = readU32()
+	f.minKeyLen = readU32()
+	f.maxKeyOffset = readU32()
+	f.maxKeyLen = readU32()
+	f.entryCount = readU32()
+
+	return f, nil
+}
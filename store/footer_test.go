@@ -0,0 +1,50 @@
+package store
+
+import "testing"
+
+func TestFooterEncodeDecodeRoundTrip(t *testing.T) {
+	f := footer{
+		version:          sstFormatVersion,
+		blockIndexOffset: 10,
+		blockIndexLen:    20,
+		minKeyOffset:     30,
+		minKeyLen:        3,
+		maxKeyOffset:     33,
+		maxKeyLen:        3,
+		entryCount:       42,
+	}
+
+	got, err := decodeFooter(f.encode())
+	if err != nil {
+		t.Fatalf("decodeFooter: %v", err)
+	}
+	if got != f {
+		t.Fatalf("decodeFooter(encode(f)) = %+v, want %+v", got, f)
+	}
+}
+
+func TestDecodeFooterRejectsBadMagic(t *testing.T) {
+	raw := footer{version: sstFormatVersion}.encode()
+	raw[0] ^= 0xFF
+
+	if _, err := decodeFooter(raw); err == nil {
+		t.Fatal("decodeFooter with corrupted magic returned no error")
+	}
+}
+
+func TestDecodeFooterRejectsChecksumMismatch(t *testing.T) {
+	raw := footer{version: sstFormatVersion, entryCount: 7}.encode()
+	raw[len(sstMagic)+1] ^= 0xFF // flip a byte inside the body, after the magic+version
+
+	if _, err := decodeFooter(raw); err == nil {
+		t.Fatal("decodeFooter with corrupted body returned no error, want checksum mismatch")
+	}
+}
+
+func TestDecodeFooterRejectsNewerVersion(t *testing.T) {
+	raw := footer{version: sstFormatVersion + 1}.encode()
+
+	if _, err := decodeFooter(raw); err == nil {
+		t.Fatal("decodeFooter with a newer format version returned no error")
+	}
+}
@@ -0,0 +1,260 @@
+package store
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// Iterator walks a sorted sequence of key/value pairs, forward only.
+// Implementations are not safe for concurrent use.
+type Iterator interface {
+	// Seek positions the iterator at the first key >= key, or leaves it
+	// invalid if no such key exists.
+	Seek(key string)
+	// Next advances to the following key. Callers must check Valid()
+	// afterwards.
+	Next()
+	Key() string
+	Value() string
+	Valid() bool
+	Close() error
+}
+
+// tombstoneAware is implemented by iterators that can report whether the
+// current record is a deletion marker. It's kept internal rather than
+// folded into Iterator so callers outside this package only ever see
+// plain key/value pairs.
+type tombstoneAware interface {
+	tombstone() bool
+}
+
+// sequenceAware is implemented by iterators that can report the sequence
+// number of the current record, letting MergingIterator break ties
+// between duplicate keys by recency instead of by which SSTable the
+// iterator happens to be reading.
+type sequenceAware interface {
+	sequenceNumber() uint64
+}
+
+// sequenceOf returns it's current sequence number, or 0 if it doesn't
+// implement sequenceAware.
+func sequenceOf(it Iterator) uint64 {
+	if sa, ok := it.(sequenceAware); ok {
+		return sa.sequenceNumber()
+	}
+	return 0
+}
+
+// sstIterator streams records forward out of a single SSTable, reading one
+// block at a time via the block index.
+type sstIterator struct {
+	sst      *SSTable
+	blockIdx int
+	blk      *block
+	pos      int
+	curKey   string
+	curVal   string
+	curSeq   uint64
+	curTomb  bool
+	valid    bool
+}
+
+// NewIterator returns an Iterator over sst's records in key order.
+func (sst *SSTable) NewIterator() Iterator {
+	return &sstIterator{sst: sst}
+}
+
+func (it *sstIterator) Seek(key string) {
+	idx := sort.Search(len(it.sst.blockIndex), func(i int) bool {
+		return it.sst.blockIndex[i].lastKey >= key
+	})
+	if idx == len(it.sst.blockIndex) {
+		it.blk = nil
+		it.valid = false
+		return
+	}
+	if err := it.loadBlock(idx); err != nil {
+		it.valid = false
+		return
+	}
+	it.pos = int(it.blk.restarts[it.blk.seekRestart(key)])
+	it.advance()
+	for it.valid && it.curKey < key {
+		it.advance()
+	}
+}
+
+func (it *sstIterator) loadBlock(idx int) error {
+	handle := it.sst.blockIndex[idx]
+	blk, err := it.sst.readBlockCached(handle)
+	if err != nil {
+		return err
+	}
+	it.blockIdx = idx
+	it.blk = blk
+	it.pos = 0
+	it.curKey = ""
+	return nil
+}
+
+// advance decodes the next record, rolling over to the next block once
+// the current one is exhausted.
+func (it *sstIterator) advance() {
+	for it.blk == nil || it.pos >= len(it.blk.payload) {
+		nextIdx := 0
+		if it.blk != nil {
+			nextIdx = it.blockIdx + 1
+		}
+		if nextIdx >= len(it.sst.blockIndex) {
+			it.valid = false
+			return
+		}
+		if err := it.loadBlock(nextIdx); err != nil {
+			it.valid = false
+			return
+		}
+	}
+
+	key, value, seqNum, tombstone, newPos := it.blk.decodeRecordAt(it.pos, it.curKey)
+	it.pos = newPos
+	it.curKey, it.curVal, it.curSeq, it.curTomb = key, value, seqNum, tombstone
+	it.valid = true
+}
+
+func (it *sstIterator) Next()                  { it.advance() }
+func (it *sstIterator) Key() string            { return it.curKey }
+func (it *sstIterator) Value() string          { return it.curVal }
+func (it *sstIterator) Valid() bool            { return it.valid }
+func (it *sstIterator) Close() error           { return nil }
+func (it *sstIterator) tombstone() bool        { return it.curTomb }
+func (it *sstIterator) sequenceNumber() uint64 { return it.curSeq }
+
+// Scan returns every non-deleted record in [startKey, endKey), reusing the
+// same block index lookup and forward decode Get relies on.
+func (sst *SSTable) Scan(startKey, endKey string) []Record {
+	it := sst.NewIterator()
+	defer it.Close()
+
+	var out []Record
+	for it.Seek(startKey); it.Valid() && it.Key() < endKey; it.Next() {
+		if ta, ok := it.(tombstoneAware); ok && ta.tombstone() {
+			continue
+		}
+		out = append(out, Record{Key: it.Key(), Value: it.Value()})
+	}
+	return out
+}
+
+// mergeHeapItem is one child iterator parked in the merge heap, tagged
+// with a rank so ties between duplicate keys resolve deterministically.
+type mergeHeapItem struct {
+	it   Iterator
+	rank uint32 // higher wins on duplicate keys
+}
+
+type mergeHeap []*mergeHeapItem
+
+func (h mergeHeap) Len() int { return len(h) }
+func (h mergeHeap) Less(i, j int) bool {
+	if h[i].it.Key() != h[j].it.Key() {
+		return h[i].it.Key() < h[j].it.Key()
+	}
+	if si, sj := sequenceOf(h[i].it), sequenceOf(h[j].it); si != sj {
+		return si > sj // higher sequence number is the more recent write
+	}
+	return h[i].rank > h[j].rank
+}
+func (h mergeHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *mergeHeap) Push(x any)   { *h = append(*h, x.(*mergeHeapItem)) }
+func (h *mergeHeap) Pop() any {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
+
+// MergingIterator merges several sorted Iterators into one sorted stream
+// via a min-heap of their current head records. When the same key appears
+// in more than one child, the copy with the higher SequenceNumber wins;
+// ties (or children whose iterator doesn't track sequence numbers) fall
+// back to rank, the position the iterator was passed in at. The losing
+// copies are silently advanced past — this is the primitive the
+// compactor uses to reconcile overlapping SSTables.
+type MergingIterator struct {
+	children []*mergeHeapItem
+	heap     mergeHeap
+	curKey   string
+	curVal   string
+	curSeq   uint64
+	curTomb  bool
+	valid    bool
+}
+
+// NewMergingIterator builds a MergingIterator over iters. Rank is assigned
+// by position, so pass iterators oldest-first: the last one wins ties,
+// which callers should arrange to be the newest SSTable.
+func NewMergingIterator(iters ...Iterator) *MergingIterator {
+	m := &MergingIterator{}
+	for i, it := range iters {
+		m.children = append(m.children, &mergeHeapItem{it: it, rank: uint32(i)})
+	}
+	m.Seek("")
+	return m
+}
+
+func (m *MergingIterator) Seek(key string) {
+	m.heap = m.heap[:0]
+	for _, c := range m.children {
+		c.it.Seek(key)
+		if c.it.Valid() {
+			m.heap = append(m.heap, c)
+		}
+	}
+	heap.Init(&m.heap)
+	m.advance()
+}
+
+func (m *MergingIterator) advance() {
+	if m.heap.Len() == 0 {
+		m.valid = false
+		return
+	}
+
+	top := m.heap[0]
+	key, value := top.it.Key(), top.it.Value()
+	seq := sequenceOf(top.it)
+	tombstone := false
+	if ta, ok := top.it.(tombstoneAware); ok {
+		tombstone = ta.tombstone()
+	}
+
+	// advance every child currently parked on this key so the next call
+	// doesn't see a stale duplicate of it
+	for m.heap.Len() > 0 && m.heap[0].it.Key() == key {
+		c := heap.Pop(&m.heap).(*mergeHeapItem)
+		c.it.Next()
+		if c.it.Valid() {
+			heap.Push(&m.heap, c)
+		}
+	}
+
+	m.curKey, m.curVal, m.curSeq, m.curTomb, m.valid = key, value, seq, tombstone, true
+}
+
+func (m *MergingIterator) Next()                  { m.advance() }
+func (m *MergingIterator) Key() string            { return m.curKey }
+func (m *MergingIterator) Value() string          { return m.curVal }
+func (m *MergingIterator) Valid() bool            { return m.valid }
+func (m *MergingIterator) tombstone() bool        { return m.curTomb }
+func (m *MergingIterator) sequenceNumber() uint64 { return m.curSeq }
+
+func (m *MergingIterator) Close() error {
+	var firstErr error
+	for _, c := range m.children {
+		if err := c.it.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
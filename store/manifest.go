@@ -0,0 +1,130 @@
+package store
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ManifestFileName is the append-only log of level edits for a store
+// directory. Replaying it from the start reconstructs which SSTables
+// belong to which level without scanning the data directory.
+const ManifestFileName = "MANIFEST"
+
+// manifestEdit records one "file added to level" or "file removed from
+// level" event produced by a compaction.
+type manifestEdit struct {
+	add     bool
+	level   int
+	fileNum uint32
+	minKey  string
+	maxKey  string
+}
+
+// manifest is the append-only edit log backing one Compactor. Every
+// append is followed by an fsync so a crash mid-compaction can never
+// leave a torn or partially-applied edit in the log.
+type manifest struct {
+	file *os.File
+}
+
+func manifestPath(directory string) string {
+	return fmt.Sprintf("../%s/%s", directory, ManifestFileName)
+}
+
+// openManifest opens (creating if necessary) the manifest log for directory.
+func openManifest(directory string) (*manifest, error) {
+	f, err := os.OpenFile(manifestPath(directory), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("open manifest: %w", err)
+	}
+	return &manifest{file: f}, nil
+}
+
+// append atomically records edits: every edit is written and fsynced
+// before this call returns, so replay never observes half of a batch.
+func (m *manifest) append(edits []manifestEdit) error {
+	buf := make([]byte, 0, 32*len(edits))
+	var scratch [binary.MaxVarintLen64]byte
+
+	for _, e := range edits {
+		action := byte(0)
+		if e.add {
+			action = 1
+		}
+		buf = append(buf, action)
+
+		n := binary.PutUvarint(scratch[:], uint64(e.level))
+		buf = append(buf, scratch[:n]...)
+		n = binary.PutUvarint(scratch[:], uint64(e.fileNum))
+		buf = append(buf, scratch[:n]...)
+
+		n = binary.PutUvarint(scratch[:], uint64(len(e.minKey)))
+		buf = append(buf, scratch[:n]...)
+		buf = append(buf, e.minKey...)
+
+		n = binary.PutUvarint(scratch[:], uint64(len(e.maxKey)))
+		buf = append(buf, scratch[:n]...)
+		buf = append(buf, e.maxKey...)
+	}
+
+	if _, err := m.file.Write(buf); err != nil {
+		return fmt.Errorf("write manifest: %w", err)
+	}
+	return m.file.Sync()
+}
+
+// replayManifest reads every edit recorded so far and returns, per level,
+// the file numbers currently live (added but not later removed).
+func replayManifest(directory string) (map[int][]uint32, error) {
+	f, err := os.Open(manifestPath(directory))
+	if os.IsNotExist(err) {
+		return map[int][]uint32{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open manifest: %w", err)
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("read manifest: %w", err)
+	}
+
+	live := map[int]map[uint32]bool{}
+	pos := 0
+	for pos < len(data) {
+		add := data[pos] == 1
+		pos++
+
+		level, n := binary.Uvarint(data[pos:])
+		pos += n
+		fileNum, n := binary.Uvarint(data[pos:])
+		pos += n
+
+		minKeyLen, n := binary.Uvarint(data[pos:])
+		pos += n + int(minKeyLen)
+
+		maxKeyLen, n := binary.Uvarint(data[pos:])
+		pos += n + int(maxKeyLen)
+
+		levelNum := int(level)
+		if live[levelNum] == nil {
+			live[levelNum] = map[uint32]bool{}
+		}
+		if add {
+			live[levelNum][uint32(fileNum)] = true
+		} else {
+			delete(live[levelNum], uint32(fileNum))
+		}
+	}
+
+	result := map[int][]uint32{}
+	for levelNum, files := range live {
+		for fileNum := range files {
+			result[levelNum] = append(result[levelNum], fileNum)
+		}
+	}
+	return result, nil
+}
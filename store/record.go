@@ -0,0 +1,22 @@
+package store
+
+// Record is an in-memory key-value pair awaiting encode into an SSTable
+// data block. The on-disk encoding (shared-prefix compression relative to
+// the previous record, varint-prefixed lengths) lives in block.go, since
+// it depends on where a record falls relative to its block's restart
+// points rather than on the record alone.
+//
+// SequenceNumber orders writes to the same key across SSTables:
+// MergingIterator's merge heap breaks ties between duplicate keys by
+// comparing SequenceNumber (the higher one wins) before falling back to
+// which SSTable the copy came from, so the result doesn't depend on
+// which level a copy happens to live in. Tombstone marks a deletion;
+// compaction carries it forward until it reaches the bottom-most level,
+// the only point where it's provable that no older version of the key
+// survives beneath it.
+type Record struct {
+	Key            string
+	Value          string
+	SequenceNumber uint64
+	Tombstone      bool
+}
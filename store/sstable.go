@@ -5,9 +5,8 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
-	"io"
 	"os"
-	"strings"
+	"sync"
 	"sync/atomic"
 
 	"github.com/tferdous17/genesis/utils"
@@ -15,23 +14,58 @@ import (
 
 const (
 	DataFileExtension  string = ".data"
-	IndexFileExtension string = ".index"
 	BloomFileExtension string = ".bloom"
-
-	SparseIndexSampleSize int = 1000
 )
 
 var sstTableCounter uint32
 
+// seedSstTableCounter raises sstTableCounter to at least maxFileNum if it
+// isn't already there. Called once with the highest file number found
+// while replaying the manifest, so a restarted process never reuses a
+// file number a live SSTable is still using.
+func seedSstTableCounter(maxFileNum uint32) {
+	for {
+		cur := atomic.LoadUint32(&sstTableCounter)
+		if maxFileNum <= cur {
+			return
+		}
+		if atomic.CompareAndSwapUint32(&sstTableCounter, cur, maxFileNum) {
+			return
+		}
+	}
+}
+
+// ErrKeyDeleted is returned by SSTable.Get when key resolves to a
+// tombstone in that table, as distinct from the key being absent from it.
+// Store.Get relies on this to stop searching older levels immediately
+// instead of falling through to a stale pre-delete value.
+var ErrKeyDeleted = errors.New("key deleted")
+
+// blockHandle locates one data block on disk: its byte offset into the
+// .data file and the length of its payload. The CRC32C checksum and
+// compression flag immediately follow the payload (see blockTrailerSize)
+// and aren't counted in length.
+type blockHandle struct {
+	lastKey string // greatest key stored in this block
+	offset  uint32
+	length  uint32
+}
+
 type SSTable struct {
 	dataFile    *os.File
-	indexFile   *os.File
 	bloomFilter *BloomFilter
+	blockCache  *BlockCache // shared across every SSTable in a Store; nil means "no cache"
 	sstCounter  uint32
+	directory   string
 	minKey      string
 	maxKey      string
 	sizeInBytes uint32
-	sparseKeys  []sparseIndex
+	blockIndex  []blockHandle
+
+	// closeMu guards against Get reading dataFile/bloomFilter.file while
+	// closeAndRemove is closing and deleting them out from under it once
+	// compaction has dropped this table from its level.
+	closeMu sync.RWMutex
 }
 
 // InitSSTableOnDisk directory to store sstable, (sorted) entries to store in said table
@@ -52,30 +86,24 @@ func (sst *SSTable) InitTableFiles(directory string) error {
 		return err
 	}
 
-	// create data and index files
+	// create data and bloom files; the block index and key bounds now
+	// live inside the data file itself, behind its footer (see footer.go)
 	dataFile, err := os.Create(getNextSstFilename(directory, sst.sstCounter) + DataFileExtension)
 
 	if err != nil {
 		return fmt.Errorf("failed to create data file: %w", err)
 	}
 
-	indexFile, err := os.Create(getNextSstFilename(directory, sst.sstCounter) + IndexFileExtension)
-
-	if err != nil {
-		dataFile.Close() // Clean up previously created files
-		return fmt.Errorf("failed to create index file: %w", err)
-	}
-
 	bloomFile, err := os.Create(getNextSstFilename(directory, sst.sstCounter) + BloomFileExtension)
 
 	if err != nil {
 		dataFile.Close() // Clean up previously created files
-		indexFile.Close()
 		return fmt.Errorf("failed to create bloom filter file: %w", err)
 	}
 
-	sst.dataFile, sst.indexFile = dataFile, indexFile
+	sst.dataFile = dataFile
 	sst.bloomFilter = NewBloomFilter(bloomFile)
+	sst.directory = directory
 
 	return nil
 }
@@ -84,59 +112,132 @@ func getNextSstFilename(directory string, sstCounter uint32) string {
 	return fmt.Sprintf("../%s/sst_%d", directory, sstCounter)
 }
 
-type sparseIndex struct {
-	keySize    uint32
-	key        string
-	byteOffset uint32 // where to start reading from
+// closeAndRemove closes sst's open file handles and deletes its .data and
+// .bloom files from disk. Called once a compaction's manifest edit has
+// durably recorded sst as removed from its level, so its files are never
+// needed again. Takes closeMu for writing so it waits out any Get that
+// grabbed sst from a level snapshot just before removal instead of
+// closing the files out from under it.
+func (sst *SSTable) closeAndRemove() {
+	sst.closeMu.Lock()
+	defer sst.closeMu.Unlock()
+
+	if err := sst.dataFile.Close(); err != nil {
+		utils.LogRED("close data file for sst_%d: %v", sst.sstCounter, err)
+	}
+	if err := sst.bloomFilter.file.Close(); err != nil {
+		utils.LogRED("close bloom file for sst_%d: %v", sst.sstCounter, err)
+	}
+
+	base := getNextSstFilename(sst.directory, sst.sstCounter)
+	if err := os.Remove(base + DataFileExtension); err != nil {
+		utils.LogRED("remove data file for sst_%d: %v", sst.sstCounter, err)
+	}
+	if err := os.Remove(base + BloomFileExtension); err != nil {
+		utils.LogRED("remove bloom file for sst_%d: %v", sst.sstCounter, err)
+	}
 }
 
+// writeEntriesToSST packs sortedEntries into fixed-size data blocks
+// (BlockSize, prefix-compressed between restart points every
+// RestartInterval records), appends the block index and a versioned
+// footer after them in the same .data file, and populates the bloom
+// filter.
 func writeEntriesToSST(sortedEntries *[]Record, table *SSTable) {
-	buf := new(bytes.Buffer)
-	var byteOffsetCounter uint32
-
-	// Keep track of min, max for searching in the case our desired key is outside these bounds
 	table.minKey = (*sortedEntries)[0].Key
 	table.maxKey = (*sortedEntries)[len(*sortedEntries)-1].Key
 
-	// * every 1000th key will be put into the sparse index
+	dataBuf := new(bytes.Buffer)
+	builder := newBlockBuilder()
+	var offset uint32
+
+	flush := func() {
+		if builder.empty() {
+			return
+		}
+		lastKey := builder.lastKey
+		encoded := builder.finish()
+		table.blockIndex = append(table.blockIndex, blockHandle{
+			lastKey: lastKey,
+			offset:  offset,
+			length:  uint32(len(encoded) - blockTrailerSize),
+		})
+		dataBuf.Write(encoded)
+		offset += uint32(len(encoded))
+		builder.reset()
+	}
+
 	for i := range *sortedEntries {
-		table.sizeInBytes += (*sortedEntries)[i].RecordSize
-		if i%SparseIndexSampleSize == 0 {
-			table.sparseKeys = append(table.sparseKeys, sparseIndex{
-				keySize:    (*sortedEntries)[i].Header.KeySize,
-				key:        (*sortedEntries)[i].Key,
-				byteOffset: byteOffsetCounter,
-			})
+		rec := (*sortedEntries)[i]
+		builder.add(rec.Key, rec.Value, rec.SequenceNumber, rec.Tombstone)
+		if builder.estimatedSize() >= BlockSize {
+			flush()
 		}
-		byteOffsetCounter += (*sortedEntries)[i].RecordSize
-		(*sortedEntries)[i].EncodeKV(buf)
 	}
+	flush()
+	table.sizeInBytes = offset
+
+	utils.Logf("BLOCK INDEX: %v", table.blockIndex)
+	appendBlockIndexAndFooter(dataBuf, table, uint32(len(*sortedEntries)))
 
-	// after encoding all entries, dump into the SSTable
-	if err := utils.WriteToFile(buf.Bytes(), table.dataFile); err != nil {
+	if err := utils.WriteToFile(dataBuf.Bytes(), table.dataFile); err != nil {
 		fmt.Println("write to sst err:", err)
 	}
-	// * Set up sparse index
-	utils.Logf("SPARSE KEYS: %v", table.sparseKeys)
-	populateSparseIndexFile(&table.sparseKeys, table.indexFile)
 
 	// * Set up + populate bloom filter
 	table.bloomFilter.InitBloomFilterAttrs(uint32(len(*sortedEntries)))
 	populateBloomFilter(sortedEntries, table.bloomFilter)
 }
 
-func populateSparseIndexFile(indices *[]sparseIndex, indexFile *os.File) {
-	// encode and write to index file
-	buf := new(bytes.Buffer)
-	for i := range *indices {
-		binary.Write(buf, binary.LittleEndian, (*indices)[i].keySize)
-		buf.WriteString((*indices)[i].key)
-		binary.Write(buf, binary.LittleEndian, (*indices)[i].byteOffset)
+// appendBlockIndexAndFooter writes the block index, the min/max key
+// bytes, and the fixed-size footer pointing at them onto the end of buf,
+// which already holds every data block.
+func appendBlockIndexAndFooter(buf *bytes.Buffer, table *SSTable, entryCount uint32) footer {
+	f := footer{version: sstFormatVersion, entryCount: entryCount}
+
+	f.blockIndexOffset = uint32(buf.Len())
+	encodeBlockIndex(buf, table.blockIndex)
+	f.blockIndexLen = uint32(buf.Len()) - f.blockIndexOffset
+
+	f.minKeyOffset = uint32(buf.Len())
+	buf.WriteString(table.minKey)
+	f.minKeyLen = uint32(len(table.minKey))
+
+	f.maxKeyOffset = uint32(buf.Len())
+	buf.WriteString(table.maxKey)
+	f.maxKeyLen = uint32(len(table.maxKey))
+
+	buf.Write(f.encode())
+	return f
+}
+
+// encodeBlockIndex serializes index as keyLen(uvarint)+key+offset(u32)+length(u32) per block.
+func encodeBlockIndex(buf *bytes.Buffer, index []blockHandle) {
+	var scratch [binary.MaxVarintLen64]byte
+	for _, h := range index {
+		writeUvarint(buf, scratch[:], uint64(len(h.lastKey)))
+		buf.WriteString(h.lastKey)
+		binary.Write(buf, binary.LittleEndian, h.offset)
+		binary.Write(buf, binary.LittleEndian, h.length)
 	}
+}
 
-	if err := utils.WriteToFile(buf.Bytes(), indexFile); err != nil {
-		fmt.Println("write to indexfile err:", err)
+// decodeBlockIndex is the inverse of encodeBlockIndex.
+func decodeBlockIndex(data []byte) []blockHandle {
+	var handles []blockHandle
+	pos := 0
+	for pos < len(data) {
+		keyLen, n := binary.Uvarint(data[pos:])
+		pos += n
+		key := string(data[pos : pos+int(keyLen)])
+		pos += int(keyLen)
+		offset := binary.LittleEndian.Uint32(data[pos:])
+		pos += 4
+		length := binary.LittleEndian.Uint32(data[pos:])
+		pos += 4
+		handles = append(handles, blockHandle{lastKey: key, offset: offset, length: length})
 	}
+	return handles
 }
 
 func populateBloomFilter(entries *[]Record, bloomFilter *BloomFilter) {
@@ -144,20 +245,19 @@ func populateBloomFilter(entries *[]Record, bloomFilter *BloomFilter) {
 		bloomFilter.Add((*entries)[i].Key)
 	}
 
-	bfBytes := make([]byte, bloomFilter.bitSetSize)
-	for i, b := range bloomFilter.bitSet {
-		if b {
-			bfBytes[i] = 1
-		} else {
-			bfBytes[i] = 0
-		}
-	}
-	if err := utils.WriteToFile(bfBytes, bloomFilter.file); err != nil {
+	if err := bloomFilter.Save(uint32(len(*entries))); err != nil {
 		fmt.Println("write to bloomfile err:", err)
 	}
 }
 
+// Get looks up key by seeking an Iterator to it and checking for an exact
+// match, sharing its code path with Scan instead of re-implementing block
+// lookup and decode. Holds closeMu for reading so a concurrent compaction
+// can't close and remove sst's files mid-lookup.
 func (sst *SSTable) Get(key string) (string, error) {
+	sst.closeMu.RLock()
+	defer sst.closeMu.RUnlock()
+
 	if key < sst.minKey || key > sst.maxKey {
 		return "<!>", utils.ErrKeyNotWithinTable
 	}
@@ -167,78 +267,103 @@ func (sst *SSTable) Get(key string) (string, error) {
 		return "", utils.ErrKeyNotWithinTable
 	}
 
-	// * Get sparse index and move to offset
-	currOffset := sst.sparseKeys[sst.getCandidateByteOffsetIndex(key)].byteOffset
-	if _, err := sst.dataFile.Seek(int64(currOffset), 0); err != nil {
-		return "", err
+	it := sst.NewIterator()
+	defer it.Close()
+
+	it.Seek(key)
+	if !it.Valid() || it.Key() != key {
+		return "", utils.ErrKeyNotFound
+	}
+	if ta, ok := it.(tombstoneAware); ok && ta.tombstone() {
+		return "", ErrKeyDeleted
 	}
-	// * start loop
-	var keyFound = false
-	var eofErr error
 
-	for keyFound == false || eofErr == nil {
-		// * set up entry for the header
-		currEntry := make([]byte, 17)
-		_, err := io.ReadFull(sst.dataFile, currEntry)
-		if errors.Is(err, io.EOF) {
-			eofErr = err
-			return "", err
-		}
+	utils.LogGREEN("FOUND KEY %s -> VALUE %s\n", key, it.Value())
+	return it.Value(), nil
+}
 
-		h := &Header{}
-		h.DecodeHeader(currEntry)
-
-		// * move the cursor so we can read the rest of the record
-		currOffset += headerSize // can do this since headerSize is constant
-		sst.dataFile.Seek(int64(currOffset), 0)
-		// * set up []byte for the rest of the record
-		currRecord := make([]byte, h.KeySize+h.ValueSize)
-		if _, err := io.ReadFull(sst.dataFile, currRecord); err != nil {
-			fmt.Println("READFULL ERR:", err)
-			return "", err
-		}
-		// * append both []byte together in order to decode as a whole
-		currEntry = append(currEntry, currRecord...) // full size of the record
-		r := &Record{}
-		r.DecodeKV(currEntry)
-		//utils.Logf("LOOKING AT RECORD: %v", r)
-
-		if r.Key == key {
-			utils.LogGREEN("FOUND KEY %s -> VALUE %s\n", key, r.Value)
-			keyFound = true
-			return r.Value, nil
-		} else if r.Key > key {
-			// * return early
-			// * this works b/c since our data is sorted, if the curr key is > target key,
-			// * ..then the key is not in this table
-			return "", utils.ErrKeyNotWithinTable
-		} else {
-			// * else, need to keep iterating & looking
-			currOffset += r.Header.KeySize + r.Header.ValueSize
-			sst.dataFile.Seek(int64(currOffset), 0)
-		}
+// OpenSSTable reopens an existing SSTable's data and bloom files from
+// disk. It reads the footer at the end of the data file, validates its
+// magic number, version, and checksum, and uses its handles to rebuild
+// the block index and key bounds without re-scanning the file. Used by
+// the compactor to rebuild level state from the manifest.
+func OpenSSTable(directory string, fileNum uint32) (*SSTable, error) {
+	base := getNextSstFilename(directory, fileNum)
 
+	dataFile, err := os.OpenFile(base+DataFileExtension, os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("open data file: %w", err)
+	}
+	bloomFile, err := os.OpenFile(base+BloomFileExtension, os.O_RDWR, 0644)
+	if err != nil {
+		dataFile.Close()
+		return nil, fmt.Errorf("open bloom file: %w", err)
+	}
+
+	sst := &SSTable{
+		dataFile:   dataFile,
+		sstCounter: fileNum,
+		directory:  directory,
 	}
 
-	return "", utils.ErrKeyNotFound
+	info, err := dataFile.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("stat data file: %w", err)
+	}
+	sst.sizeInBytes = uint32(info.Size())
+
+	footerBytes := make([]byte, footerSize)
+	if _, err := dataFile.ReadAt(footerBytes, info.Size()-int64(footerSize)); err != nil {
+		return nil, fmt.Errorf("read footer: %w", err)
+	}
+	f, err := decodeFooter(footerBytes)
+	if err != nil {
+		return nil, fmt.Errorf("sst_%d: %w", fileNum, err)
+	}
+
+	blockIndexBytes := make([]byte, f.blockIndexLen)
+	if _, err := dataFile.ReadAt(blockIndexBytes, int64(f.blockIndexOffset)); err != nil {
+		return nil, fmt.Errorf("read block index: %w", err)
+	}
+	sst.blockIndex = decodeBlockIndex(blockIndexBytes)
+
+	minKeyBytes := make([]byte, f.minKeyLen)
+	if _, err := dataFile.ReadAt(minKeyBytes, int64(f.minKeyOffset)); err != nil {
+		return nil, fmt.Errorf("read min key: %w", err)
+	}
+	sst.minKey = string(minKeyBytes)
+
+	maxKeyBytes := make([]byte, f.maxKeyLen)
+	if _, err := dataFile.ReadAt(maxKeyBytes, int64(f.maxKeyOffset)); err != nil {
+		return nil, fmt.Errorf("read max key: %w", err)
+	}
+	sst.maxKey = string(maxKeyBytes)
+
+	bloomFilter, err := LoadBloomFilter(bloomFile)
+	if err != nil {
+		return nil, fmt.Errorf("load bloom filter: %w", err)
+	}
+	sst.bloomFilter = bloomFilter
+
+	return sst, nil
 }
 
-func (sst *SSTable) getCandidateByteOffsetIndex(targetKey string) int {
-	low := 0
-	high := len(sst.sparseKeys) - 1
+// readBlockCached returns the decoded block at handle, consulting the
+// shared block cache first when sst has one configured.
+func (sst *SSTable) readBlockCached(handle blockHandle) (*block, error) {
+	if sst.blockCache == nil {
+		return readBlock(sst.dataFile, handle.offset, handle.length)
+	}
 
-	for low <= high {
-		mid := (low + high) / 2
+	key := blockCacheKey{sstCounter: sst.sstCounter, blockOffset: handle.offset}
+	if blk, ok := sst.blockCache.get(key); ok {
+		return blk, nil
+	}
 
-		cmp := strings.Compare(targetKey, sst.sparseKeys[mid].key)
-		if cmp > 0 { // targetKey > sparseKeys[mid]
-			low = mid + 1
-		} else if cmp < 0 { // targetKey < sparseKeys[mid]
-			high = mid - 1
-		} else { // equal
-			return mid
-		}
+	blk, err := readBlock(sst.dataFile, handle.offset, handle.length)
+	if err != nil {
+		return nil, err
 	}
-	utils.LogCYAN("CANDIDATE BYTE OFFSET: %d AT INDEX %d", sst.sparseKeys[low-1].byteOffset, uint32(low-1))
-	return low - 1
+	sst.blockCache.put(key, blk, int(handle.length))
+	return blk, nil
 }
@@ -0,0 +1,53 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestCloseAndRemoveWaitsForInFlightGet confirms closeAndRemove doesn't
+// close/remove a table's files while a concurrent Get still holds its
+// closeMu for reading, so an in-flight read against a just-compacted
+// table completes normally instead of failing on an already-closed file.
+func TestCloseAndRemoveWaitsForInFlightGet(t *testing.T) {
+	abs, err := os.MkdirTemp("..", "sstable_close_test_")
+	if err != nil {
+		t.Fatalf("mkdir temp: %v", err)
+	}
+	dir := filepath.Base(abs)
+	defer os.RemoveAll(abs)
+
+	sst := InitSSTableOnDisk(dir, &[]Record{{Key: "x", Value: "1"}})
+
+	sst.closeMu.RLock() // simulate a Get already in flight
+	done := make(chan struct{})
+	go func() {
+		sst.closeAndRemove()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("closeAndRemove returned while a reader still held closeMu for reading")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	base := getNextSstFilename(dir, sst.sstCounter)
+	if _, err := os.Stat(base + DataFileExtension); err != nil {
+		t.Fatalf("data file removed before reader released closeMu: %v", err)
+	}
+
+	sst.closeMu.RUnlock() // reader finishes
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("closeAndRemove did not proceed after reader released closeMu")
+	}
+
+	if _, err := os.Stat(base + DataFileExtension); !os.IsNotExist(err) {
+		t.Fatalf("data file still exists after closeAndRemove, want removed")
+	}
+}
@@ -0,0 +1,84 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/tferdous17/genesis/utils"
+)
+
+// Store is the top-level handle for one on-disk LSM tree: it owns the
+// leveled SSTables (via its Compactor) and the block cache they share.
+type Store struct {
+	directory  string
+	compactor  *Compactor
+	blockCache *BlockCache
+}
+
+// NewStore opens (or creates) the store rooted at directory, backed by a
+// shared block cache sized at blockCacheBytes.
+func NewStore(directory string, blockCacheBytes int) (*Store, error) {
+	compactor, err := NewCompactor(directory)
+	if err != nil {
+		return nil, fmt.Errorf("open compactor: %w", err)
+	}
+
+	cache := NewBlockCache(blockCacheBytes)
+	for _, lvl := range compactor.levels {
+		for _, sst := range lvl.snapshot() {
+			sst.blockCache = cache
+		}
+	}
+	compactor.blockCache = cache
+	compactor.Start()
+
+	return &Store{directory: directory, compactor: compactor, blockCache: cache}, nil
+}
+
+// Get looks up key across every level, checking the newest table in each
+// level first, so a write still sitting in L0 shadows an older version of
+// the same key awaiting compaction further down.
+func (s *Store) Get(key string) (string, error) {
+	for levelNum := range s.compactor.levels {
+		tables := s.compactor.levels[levelNum].snapshot()
+		sort.Slice(tables, func(i, j int) bool { return tables[i].sstCounter > tables[j].sstCounter })
+
+		for _, sst := range tables {
+			value, err := sst.Get(key)
+			if err == nil {
+				return value, nil
+			}
+			if errors.Is(err, ErrKeyDeleted) {
+				// A tombstone in a newer table shadows any older version of
+				// key still awaiting compaction further down; stop here
+				// instead of letting the search fall through to it.
+				return "", utils.ErrKeyNotFound
+			}
+			if !errors.Is(err, utils.ErrKeyNotWithinTable) && !errors.Is(err, utils.ErrKeyNotFound) {
+				return "", err
+			}
+		}
+	}
+	return "", utils.ErrKeyNotFound
+}
+
+// Flush writes entries as a new L0 SSTable, wired into the shared block
+// cache and handed to the compactor for leveling.
+func (s *Store) Flush(entries *[]Record) *SSTable {
+	sst := InitSSTableOnDisk(s.directory, entries)
+	sst.blockCache = s.blockCache
+	s.compactor.AddL0Table(sst)
+	return sst
+}
+
+// BlockCacheStats reports cumulative hit/miss counts for the store's
+// shared block cache.
+func (s *Store) BlockCacheStats() (hits, misses uint64) {
+	return s.blockCache.Stats()
+}
+
+// Close stops the store's background compaction loop.
+func (s *Store) Close() {
+	s.compactor.Stop()
+}
@@ -0,0 +1,52 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tferdous17/genesis/utils"
+)
+
+// newTestStore opens a Store rooted at a freshly created sibling directory
+// of the package directory (InitTableFiles/manifestPath always resolve
+// their directory argument relative to "../"), returning a cleanup func
+// that stops the compactor and removes the directory.
+func newTestStore(t *testing.T) (*Store, string) {
+	t.Helper()
+	abs, err := os.MkdirTemp("..", "store_test_")
+	if err != nil {
+		t.Fatalf("mkdir temp: %v", err)
+	}
+	dir := filepath.Base(abs)
+
+	s, err := NewStore(dir, 0)
+	if err != nil {
+		os.RemoveAll(abs)
+		t.Fatalf("new store: %v", err)
+	}
+	t.Cleanup(func() {
+		s.Close()
+		os.RemoveAll(abs)
+	})
+	return s, dir
+}
+
+// TestStoreGetStopsAtTombstone reproduces: flush {x:"old"}, flush a
+// tombstone for x, then Get("x") must report not-found instead of falling
+// through to the older, pre-delete value sitting in L0 beneath it.
+func TestStoreGetStopsAtTombstone(t *testing.T) {
+	s, _ := newTestStore(t)
+
+	old := []Record{{Key: "x", Value: "old"}}
+	s.Flush(&old)
+
+	tombstone := []Record{{Key: "x", Tombstone: true}}
+	s.Flush(&tombstone)
+
+	_, err := s.Get("x")
+	if !errors.Is(err, utils.ErrKeyNotFound) {
+		t.Fatalf("Get(x) = %v, want %v", err, utils.ErrKeyNotFound)
+	}
+}